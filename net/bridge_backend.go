@@ -0,0 +1,128 @@
+package net
+
+import (
+	"fmt"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/weaveworks/weave/common/odp"
+)
+
+// A BridgeBackend knows how to attach and detach a veth (or other) link to
+// a particular kind of weave bridge datapath - linux-bridge, openvswitch,
+// fastdp, etc. Backends are probed in registration order against the link
+// named by the user's --datapath/--no-fastdp configuration, so the veth
+// setup code in CreateAndAttachVeth never needs to know which kind of
+// bridge it is talking to.
+type BridgeBackend interface {
+	Attach(link netlink.Link) error
+	Detach(link netlink.Link) error
+	// IsAttached reports whether link is already attached via this
+	// backend, so callers reconciling existing state (see
+	// reconcileExistingVeth) can tell a no-op Attach from one that needs
+	// to run again. Backends cannot share a single netlink-field check for
+	// this - only linuxBridgeBackend sets IFLA_MASTER - so each backend
+	// answers it in terms of its own attachment mechanism.
+	IsAttached(link netlink.Link) bool
+}
+
+// A BridgeBackendProbe inspects bridge, the link named by the configured
+// bridge name, and returns a BridgeBackend able to drive it, or nil if this
+// backend doesn't recognise the link.
+type BridgeBackendProbe func(bridgeName string, bridge netlink.Link) (BridgeBackend, error)
+
+var bridgeBackendProbes []BridgeBackendProbe
+
+// RegisterBridgeBackend adds probe to the list consulted by Probe. Backends
+// register themselves from an init() function.
+func RegisterBridgeBackend(probe BridgeBackendProbe) {
+	bridgeBackendProbes = append(bridgeBackendProbes, probe)
+}
+
+// Probe looks up bridgeName and returns the first registered BridgeBackend
+// that claims it.
+func Probe(bridgeName string) (BridgeBackend, netlink.Link, error) {
+	bridge, err := netlink.LinkByName(bridgeName)
+	if err != nil {
+		return nil, nil, fmt.Errorf(`bridge "%s" not present; did you launch weave?`, bridgeName)
+	}
+	for _, probe := range bridgeBackendProbes {
+		backend, err := probe(bridgeName, bridge)
+		if err != nil {
+			return nil, nil, err
+		}
+		if backend != nil {
+			return backend, bridge, nil
+		}
+	}
+	return nil, nil, fmt.Errorf(`device "%s" is not a bridge`, bridgeName)
+}
+
+func init() {
+	RegisterBridgeBackend(probeLinuxBridge)
+	// probeFastdp must run before probeODP: a fastdp-tagged bridge can
+	// itself surface as a plain Device/GenericLink, which probeODP would
+	// otherwise be happy to claim.
+	RegisterBridgeBackend(probeFastdp)
+	RegisterBridgeBackend(probeODP)
+}
+
+// linuxBridgeBackend attaches via the kernel's native bridge device.
+type linuxBridgeBackend struct {
+	index int
+}
+
+func probeLinuxBridge(bridgeName string, bridge netlink.Link) (BridgeBackend, error) {
+	if _, ok := bridge.(*netlink.Bridge); !ok {
+		return nil, nil
+	}
+	return linuxBridgeBackend{index: bridge.Attrs().Index}, nil
+}
+
+func (b linuxBridgeBackend) Attach(link netlink.Link) error {
+	return netlink.LinkSetMasterByIndex(link, b.index)
+}
+
+func (b linuxBridgeBackend) Detach(link netlink.Link) error {
+	return netlink.LinkSetNoMaster(link)
+}
+
+func (b linuxBridgeBackend) IsAttached(link netlink.Link) bool {
+	return link.Attrs().MasterIndex == b.index
+}
+
+// odpBackend attaches via the openvswitch/ODP datapath. The kernel reports
+// an openvswitch bridge as a GenericLink of type "openvswitch" in recent
+// versions, but older kernels report it as a plain Device, so both are
+// accepted here.
+type odpBackend struct {
+	bridgeName string
+}
+
+func probeODP(bridgeName string, bridge netlink.Link) (BridgeBackend, error) {
+	switch l := bridge.(type) {
+	case *netlink.GenericLink:
+		if l.Type() != "openvswitch" {
+			return nil, nil
+		}
+	case *netlink.Device:
+		// Assume it's our openvswitch device, and the kernel has not been
+		// updated to report the kind.
+	default:
+		return nil, nil
+	}
+	return odpBackend{bridgeName: bridgeName}, nil
+}
+
+func (b odpBackend) Attach(link netlink.Link) error {
+	return odp.AddDatapathInterface(b.bridgeName, link.Attrs().Name)
+}
+
+func (b odpBackend) Detach(link netlink.Link) error {
+	return odp.RemoveDatapathInterface(b.bridgeName, link.Attrs().Name)
+}
+
+func (b odpBackend) IsAttached(link netlink.Link) bool {
+	attached, err := odp.DatapathInterfaceExists(b.bridgeName, link.Attrs().Name)
+	return err == nil && attached
+}
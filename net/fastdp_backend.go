@@ -0,0 +1,99 @@
+package net
+
+import (
+	"fmt"
+
+	"github.com/vishvananda/netlink"
+)
+
+// ethPAll is ETH_P_ALL (0x0003, as defined in linux/if_ether.h), used as the
+// tc filter protocol to match every frame regardless of ethertype.
+const ethPAll = 0x0003
+
+// fastdpBackend attaches via a tc/ebpf datapath: rather than mastering the
+// veth to a kernel bridge or handing it to ODP, it gives the veth a clsact
+// qdisc with an ingress filter that mirred-redirects every frame to the
+// fastdp bridge link, so the kernel's tc layer does the forwarding. The
+// bridge link itself is a plain dummy/GenericLink tagged with the
+// "weave-fastdp" alias so Probe can recognise it.
+type fastdpBackend struct {
+	bridgeName  string
+	bridgeIndex int
+}
+
+const fastdpAlias = "weave-fastdp"
+
+func probeFastdp(bridgeName string, bridge netlink.Link) (BridgeBackend, error) {
+	if bridge.Attrs().Alias != fastdpAlias {
+		return nil, nil
+	}
+	return fastdpBackend{bridgeName: bridgeName, bridgeIndex: bridge.Attrs().Index}, nil
+}
+
+func clsactQdisc(linkIndex int) *netlink.GenericQdisc {
+	return &netlink.GenericQdisc{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: linkIndex,
+			Parent:    netlink.HANDLE_CLSACT,
+		},
+		QdiscType: "clsact",
+	}
+}
+
+// redirectFilter builds the ingress u32 filter that mirred-redirects every
+// frame arriving on linkIndex to targetIndex.
+func redirectFilter(linkIndex, targetIndex int) *netlink.U32 {
+	return &netlink.U32{
+		FilterAttrs: netlink.FilterAttrs{
+			LinkIndex: linkIndex,
+			Parent:    netlink.HANDLE_MIN_INGRESS,
+			Protocol:  ethPAll,
+		},
+		Actions: []netlink.Action{
+			&netlink.MirredAction{
+				ActionAttrs:  netlink.ActionAttrs{Action: netlink.TC_ACT_STOLEN},
+				Ifindex:      targetIndex,
+				MirredAction: netlink.TCA_EGRESS_REDIR,
+			},
+		},
+	}
+}
+
+func (b fastdpBackend) Attach(link netlink.Link) error {
+	if err := netlink.QdiscAdd(clsactQdisc(link.Attrs().Index)); err != nil {
+		return fmt.Errorf("failed to attach clsact qdisc to %s: %s", link.Attrs().Name, err)
+	}
+	if err := netlink.FilterAdd(redirectFilter(link.Attrs().Index, b.bridgeIndex)); err != nil {
+		return fmt.Errorf("failed to redirect %s into fastdp: %s", link.Attrs().Name, err)
+	}
+	return nil
+}
+
+func (b fastdpBackend) IsAttached(link netlink.Link) bool {
+	filters, err := netlink.FilterList(link, netlink.HANDLE_MIN_INGRESS)
+	if err != nil {
+		return false
+	}
+	for _, f := range filters {
+		u32, ok := f.(*netlink.U32)
+		if !ok {
+			continue
+		}
+		for _, a := range u32.Actions {
+			if mirred, ok := a.(*netlink.MirredAction); ok && mirred.Ifindex == b.bridgeIndex {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (b fastdpBackend) Detach(link netlink.Link) error {
+	if err := netlink.FilterDel(redirectFilter(link.Attrs().Index, b.bridgeIndex)); err != nil {
+		return fmt.Errorf("failed to remove fastdp redirect from %s: %s", link.Attrs().Name, err)
+	}
+	if err := netlink.QdiscDel(clsactQdisc(link.Attrs().Index)); err != nil {
+		return fmt.Errorf("failed to detach clsact qdisc from %s: %s", link.Attrs().Name, err)
+	}
+	return nil
+}
@@ -2,24 +2,57 @@ package net
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net"
+	"strings"
+	"time"
 
 	"github.com/j-keck/arping"
 	"github.com/vishvananda/netlink"
 	"github.com/vishvananda/netns"
-
-	"github.com/weaveworks/weave/common/odp"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
 )
 
+// all-nodes multicast address, used as the destination for unsolicited
+// Neighbor Advertisements announcing a newly-configured IPv6 address
+var ipv6AllNodes = net.ParseIP("ff02::1")
+
+// LinkByNameRetryBudget bounds how long retryLinkByName keeps retrying
+// netlink.LinkByName after moving a link into a netns, to tolerate the
+// kernel not having finished propagating it yet. It's a package-level var
+// so tests can shrink it.
+var LinkByNameRetryBudget = time.Second
+
+// retryLinkByName is netlink.LinkByName with a bounded exponential backoff,
+// for use immediately after netlink.LinkSetNsFd, where under load the
+// kernel occasionally hasn't finished moving the peer by the time we look
+// it up. WithNetNSLink does not use it: its lookup is a single
+// netlink.LinkByName with no retry.
+func retryLinkByName(name string) (netlink.Link, error) {
+	backoff := 10 * time.Millisecond
+	deadline := time.Now().Add(LinkByNameRetryBudget)
+	for {
+		link, err := netlink.LinkByName(name)
+		if err == nil || time.Now().After(deadline) {
+			return link, err
+		}
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > 200*time.Millisecond {
+			backoff = 200 * time.Millisecond
+		}
+	}
+}
+
 // create and attach local name to the Weave bridge
 func CreateAndAttachVeth(localName, peerName, bridgeName string, mtu int, init func(local, guest netlink.Link) error) (*netlink.Veth, error) {
-	maybeBridge, err := netlink.LinkByName(bridgeName)
+	backend, bridge, err := Probe(bridgeName)
 	if err != nil {
-		return nil, fmt.Errorf(`bridge "%s" not present; did you launch weave?`, bridgeName)
+		return nil, err
 	}
 
 	if mtu == 0 {
-		mtu = maybeBridge.Attrs().MTU
+		mtu = bridge.Attrs().MTU
 	}
 	local := &netlink.Veth{
 		LinkAttrs: netlink.LinkAttrs{
@@ -36,29 +69,12 @@ func CreateAndAttachVeth(localName, peerName, bridgeName string, mtu int, init f
 		return nil, fmt.Errorf(format, a...)
 	}
 
-	switch maybeBridge.(type) {
-	case *netlink.Bridge:
-		if err := netlink.LinkSetMasterByIndex(local, maybeBridge.Attrs().Index); err != nil {
-			return cleanup(`unable to set master of %s: %s`, local.Name, err)
-		}
-	case *netlink.GenericLink:
-		if maybeBridge.Type() != "openvswitch" {
-			return cleanup(`device "%s" is of type "%s"`, bridgeName, maybeBridge.Type())
-		}
-		if err := odp.AddDatapathInterface(bridgeName, local.Name); err != nil {
-			return cleanup(`failed to attach %s to device "%s": %s`, local.Name, bridgeName, err)
-		}
-	case *netlink.Device:
-		// Assume it's our openvswitch device, and the kernel has not been updated to report the kind.
-		if err := odp.AddDatapathInterface(bridgeName, local.Name); err != nil {
-			return cleanup(`failed to attach %s to device "%s": %s`, local.Name, bridgeName, err)
-		}
-	default:
-		return cleanup(`device "%s" is not a bridge`, bridgeName)
+	if err := backend.Attach(local); err != nil {
+		return cleanup(`unable to attach %s to "%s": %s`, local.Name, bridgeName, err)
 	}
 
 	if init != nil {
-		guest, err := netlink.LinkByName(peerName)
+		guest, err := retryLinkByName(peerName)
 		if err != nil {
 			return cleanup("unable to find guest veth %s: %s", peerName, err)
 		}
@@ -86,7 +102,7 @@ func SetupGuest(guest netlink.Link, name string) error {
 }
 
 func AddAddresses(guest netlink.Link, cidrs []*net.IPNet) (newAddrs []*net.IPNet, err error) {
-	existingAddrs, err := netlink.AddrList(guest, netlink.FAMILY_V4)
+	existingAddrs, err := netlink.AddrList(guest, netlink.FAMILY_ALL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get IP address for %q: %v", guest.Attrs().Name, err)
 	}
@@ -102,15 +118,78 @@ func AddAddresses(guest netlink.Link, cidrs []*net.IPNet) (newAddrs []*net.IPNet
 	return newAddrs, nil
 }
 
+// contains reports whether addrs already holds addr, matching on both IP
+// and prefix length so that e.g. a /64 and a /128 for the same address are
+// treated as distinct.
 func contains(addrs []netlink.Addr, addr *net.IPNet) bool {
+	ones, _ := addr.Mask.Size()
 	for _, x := range addrs {
-		if addr.IP.Equal(x.IPNet.IP) {
+		xOnes, _ := x.IPNet.Mask.Size()
+		if addr.IP.Equal(x.IPNet.IP) && ones == xOnes {
 			return true
 		}
 	}
 	return false
 }
 
+// announce sends a gratuitous ARP for a v4 address or an unsolicited IPv6
+// Neighbor Advertisement for a v6 address, so that neighbors on the link
+// pick up the container's new address/MAC pairing without waiting to be
+// asked for it.
+func announce(ifName string, mac net.HardwareAddr, ipnet *net.IPNet) error {
+	if ipnet.IP.To4() != nil {
+		return arping.GratuitousArpOverIfaceByName(ipnet.IP, ifName)
+	}
+	return sendUnsolicitedNA(ifName, mac, ipnet.IP)
+}
+
+// sendUnsolicitedNA broadcasts an unsolicited Neighbor Advertisement (ICMPv6
+// type 136) for ip to the all-nodes multicast group, carrying a
+// target-link-layer-address option so peers can update their neighbor cache
+// without issuing a Neighbor Solicitation first.
+func sendUnsolicitedNA(ifName string, mac net.HardwareAddr, ip net.IP) error {
+	iface, err := net.InterfaceByName(ifName)
+	if err != nil {
+		return fmt.Errorf("failed to look up interface %q: %v", ifName, err)
+	}
+
+	conn, err := icmp.ListenPacket("ip6:ipv6-icmp", "::")
+	if err != nil {
+		return fmt.Errorf("failed to open ICMPv6 socket: %v", err)
+	}
+	defer conn.Close()
+
+	pconn := conn.IPv6PacketConn()
+	if err := pconn.SetMulticastInterface(iface); err != nil {
+		return fmt.Errorf("failed to set multicast interface %q: %v", ifName, err)
+	}
+
+	msg := icmp.Message{
+		Type: ipv6.ICMPTypeNeighborAdvertisement,
+		Code: 0,
+		Body: &icmp.DefaultMessageBody{
+			Data: append(append([]byte{0x20, 0x00, 0x00, 0x00}, ip.To16()...),
+				targetLinkLayerOption(mac)...),
+		},
+	}
+	wb, err := msg.Marshal(nil)
+	if err != nil {
+		return fmt.Errorf("failed to marshal neighbor advertisement: %v", err)
+	}
+	_, err = pconn.WriteTo(wb, nil, &net.IPAddr{IP: ipv6AllNodes, Zone: ifName})
+	return err
+}
+
+// targetLinkLayerOption builds the ICMPv6 Target Link-Layer Address option
+// (type 2) carrying mac.
+func targetLinkLayerOption(mac net.HardwareAddr) []byte {
+	opt := make([]byte, 2+len(mac))
+	opt[0] = 2 // Target Link-Layer Address
+	opt[1] = byte((len(opt) + 7) / 8)
+	copy(opt[2:], mac)
+	return opt
+}
+
 func interfaceExistsInNamespace(ns netns.NsHandle, ifName string) bool {
 	err := WithNetNS(ns, func() error {
 		_, err := netlink.LinkByName(ifName)
@@ -120,11 +199,16 @@ func interfaceExistsInNamespace(ns netns.NsHandle, ifName string) bool {
 }
 
 func AttachContainer(ns netns.NsHandle, id, ifName, bridgeName string, mtu int, withMulticastRoute bool, cidrs []*net.IPNet) error {
-	if !interfaceExistsInNamespace(ns, ifName) {
-		if len(id) > 5 {
-			id = id[:5]
+	if len(id) > 5 {
+		id = id[:5]
+	}
+	name, peerName := "vethwepl"+id, "vethwg"+id
+
+	if interfaceExistsInNamespace(ns, ifName) {
+		if err := reconcileExistingVeth(ns, ifName, name, bridgeName, mtu); err != nil {
+			return err
 		}
-		name, peerName := "vethwepl"+id, "vethwg"+id
+	} else {
 		_, err := CreateAndAttachVeth(name, peerName, bridgeName, mtu, func(local, guest netlink.Link) error {
 			EthtoolTXOff(peerName) // TODO: do we want to do this under fastdp?
 			if err := netlink.LinkSetNsFd(guest, int(ns)); err != nil {
@@ -151,7 +235,10 @@ func AttachContainer(ns netns.NsHandle, id, ifName, bridgeName string, mtu int,
 			return err
 		}
 		for _, ipnet := range newAddresses {
-			arping.GratuitousArpOverIfaceByName(ipnet.IP, ifName)
+			if err := announce(ifName, guest.Attrs().HardwareAddr, ipnet); err != nil {
+				// Best-effort: a failure to announce shouldn't fail the attach.
+				continue
+			}
 		}
 		if withMulticastRoute {
 			/* Route multicast packets across the weave network.
@@ -178,7 +265,7 @@ func AttachContainer(ns netns.NsHandle, id, ifName, bridgeName string, mtu int,
 
 func DetachContainer(ns netns.NsHandle, id, ifName string, cidrs []*net.IPNet) error {
 	return WithNetNSLink(ns, ifName, func(guest netlink.Link) error {
-		existingAddrs, err := netlink.AddrList(guest, netlink.FAMILY_V4)
+		existingAddrs, err := netlink.AddrList(guest, netlink.FAMILY_ALL)
 		if err != nil {
 			return fmt.Errorf("failed to get IP address for %q: %v", guest.Attrs().Name, err)
 		}
@@ -190,15 +277,361 @@ func DetachContainer(ns netns.NsHandle, id, ifName string, cidrs []*net.IPNet) e
 				return fmt.Errorf("failed to remove IP address from %q: %v", guest.Attrs().Name, err)
 			}
 		}
-		addrs, err := netlink.AddrList(guest, netlink.FAMILY_V4)
+		v4Addrs, err := netlink.AddrList(guest, netlink.FAMILY_V4)
+		if err != nil {
+			return fmt.Errorf("failed to get IP address for %q: %v", guest.Attrs().Name, err)
+		}
+		v6Addrs, err := netlink.AddrList(guest, netlink.FAMILY_V6)
 		if err != nil {
 			return fmt.Errorf("failed to get IP address for %q: %v", guest.Attrs().Name, err)
 		}
-		if len(addrs) == 0 { // all addresses gone: remove the interface
+		if !hasConfiguredAddress(v4Addrs) && !hasConfiguredAddress(v6Addrs) {
+			// both families empty of anything but kernel-assigned
+			// link-local/auto addresses: remove the interface
 			if err := netlink.LinkDel(guest); err != nil {
 				return err
 			}
 		}
 		return nil
 	})
-}
\ No newline at end of file
+}
+
+// hasConfiguredAddress reports whether addrs contains anything beyond a
+// kernel-assigned link-local/autoconf address (e.g. the fe80::/64 address
+// every IPv6-capable veth gets the moment it's brought up, regardless of
+// whether a v6 CIDR was ever configured on it).
+func hasConfiguredAddress(addrs []netlink.Addr) bool {
+	for _, addr := range addrs {
+		if addr.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if addr.Scope == int(netlink.SCOPE_LINK) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// MacvlanMode selects the mode a macvlan/macvtap sub-interface operates in.
+type MacvlanMode string
+
+const (
+	MacvlanModeBridge   MacvlanMode = "bridge"
+	MacvlanModePrivate  MacvlanMode = "private"
+	MacvlanModeVEPA     MacvlanMode = "vepa"
+	MacvlanModePassthru MacvlanMode = "passthru"
+)
+
+func macvlanMode(mode MacvlanMode) (netlink.MacvlanMode, error) {
+	switch mode {
+	case MacvlanModeBridge:
+		return netlink.MACVLAN_MODE_BRIDGE, nil
+	case MacvlanModePrivate:
+		return netlink.MACVLAN_MODE_PRIVATE, nil
+	case MacvlanModeVEPA:
+		return netlink.MACVLAN_MODE_VEPA, nil
+	case MacvlanModePassthru:
+		return netlink.MACVLAN_MODE_PASSTHRU, nil
+	default:
+		return 0, fmt.Errorf(`unknown macvlan mode "%s"`, mode)
+	}
+}
+
+// AttachContainerMacvlan creates a macvlan sub-interface off parent and
+// moves it into the container netns as ifName, bypassing the weave bridge
+// entirely. It is an alternative to AttachContainer for workloads that need
+// near-line-rate throughput; weave's IPAM/DNS can still be used to pick the
+// cidrs to configure on the resulting interface.
+func AttachContainerMacvlan(ns netns.NsHandle, id, ifName, parent string, mode MacvlanMode, mtu int, cidrs []*net.IPNet) error {
+	if interfaceExistsInNamespace(ns, ifName) {
+		return WithNetNSLink(ns, ifName, func(guest netlink.Link) error {
+			return bringUpWithAddresses(ifName, guest, cidrs)
+		})
+	}
+
+	parentLink, err := netlink.LinkByName(parent)
+	if err != nil {
+		return fmt.Errorf(`parent link "%s" not found: %s`, parent, err)
+	}
+	if mtu == 0 {
+		mtu = parentLink.Attrs().MTU
+	}
+	nlMode, err := macvlanMode(mode)
+	if err != nil {
+		return err
+	}
+
+	if len(id) > 5 {
+		id = id[:5]
+	}
+	tmpName := "vethwml" + id
+	mv := &netlink.Macvlan{
+		LinkAttrs: netlink.LinkAttrs{
+			Name:        tmpName,
+			MTU:         mtu,
+			ParentIndex: parentLink.Attrs().Index,
+		},
+		Mode: nlMode,
+	}
+	if err := netlink.LinkAdd(mv); err != nil {
+		return fmt.Errorf(`could not create macvlan interface off "%s": %s`, parent, err)
+	}
+	cleanup := func(format string, a ...interface{}) error {
+		netlink.LinkDel(mv)
+		return fmt.Errorf(format, a...)
+	}
+	if err := netlink.LinkSetNsFd(mv, int(ns)); err != nil {
+		return cleanup("failed to move macvlan interface to container netns: %s", err)
+	}
+
+	return WithNetNS(ns, func() error {
+		guest, err := retryLinkByName(tmpName)
+		if err != nil {
+			return fmt.Errorf("unable to find macvlan interface %s in container netns: %s", tmpName, err)
+		}
+		if err := SetupGuest(guest, ifName); err != nil {
+			return err
+		}
+		return bringUpWithAddresses(ifName, guest, cidrs)
+	})
+}
+
+// DetachContainerMacvlan removes the macvlan interface ifName from the
+// container netns.
+func DetachContainerMacvlan(ns netns.NsHandle, ifName string) error {
+	return WithNetNSLink(ns, ifName, func(guest netlink.Link) error {
+		return netlink.LinkDel(guest)
+	})
+}
+
+// AttachContainerSRIOV moves the SR-IOV virtual function vfIndex of physical
+// function pfName into the container netns as ifName, for workloads that
+// need a dedicated hardware queue rather than a software datapath. Like
+// AttachContainerMacvlan, this bypasses the weave bridge; weave's IPAM/DNS
+// can still be used to pick the cidrs to configure on the resulting
+// interface.
+func AttachContainerSRIOV(ns netns.NsHandle, id, ifName, pfName string, vfIndex int, cidrs []*net.IPNet) error {
+	if interfaceExistsInNamespace(ns, ifName) {
+		return WithNetNSLink(ns, ifName, func(guest netlink.Link) error {
+			return bringUpWithAddresses(ifName, guest, cidrs)
+		})
+	}
+
+	vfName, err := sriovVFLinkName(pfName, vfIndex)
+	if err != nil {
+		return err
+	}
+	vf, err := netlink.LinkByName(vfName)
+	if err != nil {
+		return fmt.Errorf(`VF %d of "%s" not found as "%s": %s`, vfIndex, pfName, vfName, err)
+	}
+	if err := netlink.LinkSetNsFd(vf, int(ns)); err != nil {
+		return fmt.Errorf("failed to move VF %s to container netns: %s", vfName, err)
+	}
+
+	return WithNetNS(ns, func() error {
+		guest, err := retryLinkByName(vfName)
+		if err != nil {
+			return fmt.Errorf("unable to find VF %s in container netns: %s", vfName, err)
+		}
+		if err := SetupGuest(guest, ifName); err != nil {
+			return err
+		}
+		return bringUpWithAddresses(ifName, guest, cidrs)
+	})
+}
+
+// DetachContainerSRIOV returns VF vfIndex of pfName from ns to initNs,
+// renamed back to its stable "dev<index>" name so it is ready to be handed
+// to another container later. initNs is the host/root netns the VF lived
+// in before AttachContainerSRIOV moved it - callers pass the same handle
+// they use for their own host-side netlink operations, rather than weave
+// assuming it shares a pid namespace with pid 1.
+func DetachContainerSRIOV(ns netns.NsHandle, initNs netns.NsHandle, ifName, pfName string, vfIndex int) error {
+	return WithNetNSLink(ns, ifName, func(guest netlink.Link) error {
+		if err := netlink.LinkSetNsFd(guest, int(initNs)); err != nil {
+			return fmt.Errorf("failed to move VF back to init netns: %s", err)
+		}
+		return WithNetNS(initNs, func() error {
+			vf, err := retryLinkByName(ifName)
+			if err != nil {
+				return fmt.Errorf("unable to find VF %s in init netns: %s", ifName, err)
+			}
+			return netlink.LinkSetName(vf, fmt.Sprintf("dev%d", vfIndex))
+		})
+	})
+}
+
+// sriovVFLinkName resolves the network interface name of VF vfIndex for the
+// physical function pfName, via the kernel's
+// /sys/class/net/<pf>/device/virtfn<n>/net/ layout.
+func sriovVFLinkName(pfName string, vfIndex int) (string, error) {
+	base := fmt.Sprintf("/sys/class/net/%s/device/virtfn%d/net", pfName, vfIndex)
+	entries, err := ioutil.ReadDir(base)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve VF %d of %s: %s", vfIndex, pfName, err)
+	}
+	if len(entries) == 0 {
+		return "", fmt.Errorf("no net device found for VF %d of %s", vfIndex, pfName)
+	}
+	return entries[0].Name(), nil
+}
+
+// bringUpWithAddresses configures cidrs on guest, brings it up, and
+// announces the newly-added addresses to the link.
+func bringUpWithAddresses(ifName string, guest netlink.Link, cidrs []*net.IPNet) error {
+	newAddresses, err := AddAddresses(guest, cidrs)
+	if err != nil {
+		return err
+	}
+	if err := netlink.LinkSetUp(guest); err != nil {
+		return err
+	}
+	for _, ipnet := range newAddresses {
+		if err := announce(ifName, guest.Attrs().HardwareAddr, ipnet); err != nil {
+			continue
+		}
+	}
+	return nil
+}
+
+// ReconcileARPForVeths re-announces every configured address on every
+// weave-managed veth in the netns of process pid, and re-runs
+// ConfigureARPCache on each. Links are selected by name prefix (e.g.
+// "vethwepl"). Unlike the gratuitous ARP/NA sent inline by AttachContainer
+// for newly-added addresses, this revisits addresses that were already
+// configured, so it's suitable for a periodic sweeper or for use after
+// weave itself restarts, when upstream MACs may have changed or peers may
+// have stale neighbor entries.
+func ReconcileARPForVeths(pid int, prefix string) error {
+	ns, err := netns.GetFromPid(pid)
+	if err != nil {
+		return fmt.Errorf("failed to get netns for pid %d: %s", pid, err)
+	}
+	defer ns.Close()
+
+	return WithNetNS(ns, func() error {
+		links, err := netlink.LinkList()
+		if err != nil {
+			return fmt.Errorf("failed to list links: %s", err)
+		}
+		for _, link := range links {
+			name := link.Attrs().Name
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			if err := reconcileARPForLink(name, link); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ARPReconcileInterval is a period to pass as StartARPReconciler's
+// interval; StartARPReconciler does not fall back to it on its own.
+const ARPReconcileInterval = 30 * time.Second
+
+// StartARPReconciler runs ReconcileARPForVeths(pid, prefix) once immediately
+// - covering the reclaim path right after weave itself restarts, when
+// neighbor entries left over from before the restart may be stale - and
+// then again every interval for as long as the process runs, until stop is
+// closed. Like a failed announce elsewhere in this file, a failed sweep is
+// swallowed rather than propagated: it will simply be retried on the next
+// tick.
+func StartARPReconciler(pid int, prefix string, interval time.Duration, stop <-chan struct{}) {
+	sweep := func() {
+		if err := ReconcileARPForVeths(pid, prefix); err != nil {
+			return
+		}
+	}
+	sweep()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sweep()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func reconcileARPForLink(name string, link netlink.Link) error {
+	if err := ConfigureARPCache(name); err != nil {
+		return fmt.Errorf("failed to configure ARP cache for %q: %s", name, err)
+	}
+	addrs, err := netlink.AddrList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return fmt.Errorf("failed to get IP address for %q: %v", name, err)
+	}
+	for _, addr := range addrs {
+		if err := announce(name, link.Attrs().HardwareAddr, addr.IPNet); err != nil {
+			// Best-effort: a failure to re-announce one address/link
+			// shouldn't abort the sweep over the rest.
+			continue
+		}
+	}
+	return nil
+}
+
+// reconcileExistingVeth is called by AttachContainer when the guest
+// interface ifName already exists in ns, e.g. because attach is being
+// retried after a crash or during an upgrade. It verifies the host-side
+// peer is still mastered to bridgeName with the right MTU and the
+// "vethwepl<id>" naming convention used by this version of weave, fixing
+// up whatever has drifted rather than assuming a prior successful attach
+// left everything in place.
+func reconcileExistingVeth(ns netns.NsHandle, ifName, expectedHostName, bridgeName string, mtu int) error {
+	var peerIndex int
+	if err := WithNetNSLink(ns, ifName, func(guest netlink.Link) error {
+		peerIndex = guest.Attrs().ParentIndex
+		return nil
+	}); err != nil {
+		return fmt.Errorf("unable to inspect existing interface %q: %s", ifName, err)
+	}
+
+	hostPeer, err := netlink.LinkByIndex(peerIndex)
+	if err != nil {
+		return fmt.Errorf("unable to find host peer of %q: %s", ifName, err)
+	}
+
+	if hostPeer.Attrs().Name != expectedHostName {
+		// A prior weave version may have used a different naming scheme;
+		// rename to the current convention so later reconciliation and
+		// ReconcileARPForVeths can find it by prefix.
+		if err := netlink.LinkSetName(hostPeer, expectedHostName); err != nil {
+			return fmt.Errorf("unable to rename host peer %q to %q: %s", hostPeer.Attrs().Name, expectedHostName, err)
+		}
+		hostPeer.Attrs().Name = expectedHostName
+	}
+
+	backend, bridge, err := Probe(bridgeName)
+	if err != nil {
+		return err
+	}
+	if !backend.IsAttached(hostPeer) {
+		if err := backend.Attach(hostPeer); err != nil {
+			return fmt.Errorf("unable to re-attach %q to %q: %s", expectedHostName, bridgeName, err)
+		}
+	}
+
+	if mtu == 0 {
+		mtu = bridge.Attrs().MTU
+	}
+	if hostPeer.Attrs().MTU != mtu {
+		if err := netlink.LinkSetMTU(hostPeer, mtu); err != nil {
+			return fmt.Errorf("unable to reset MTU on %q: %s", expectedHostName, err)
+		}
+	}
+
+	if err := netlink.LinkSetUp(hostPeer); err != nil {
+		return fmt.Errorf("unable to bring %q up: %s", expectedHostName, err)
+	}
+
+	return nil
+}